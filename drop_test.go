@@ -0,0 +1,58 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDropVersionPinnedReplace(t *testing.T) {
+	dir := t.TempDir()
+	modPath := filepath.Join(dir, "go.mod")
+	original := "module example.com/app\n\ngo 1.21\n\nrequire example.com/dep v1.0.0\n\nreplace example.com/dep v1.0.0 => ../dep\n"
+	if err := os.WriteFile(modPath, []byte(original), 0644); err != nil {
+		t.Fatalf("failed to write go.mod: %v", err)
+	}
+
+	f, err := parseGoMod(modPath)
+	if err != nil {
+		t.Fatalf("parseGoMod: %v", err)
+	}
+
+	targets := matchingReplaces(f, "dep")
+	if len(targets) != 1 {
+		t.Fatalf("expected 1 matching replace, got %d", len(targets))
+	}
+	if targets[0].Version != "v1.0.0" {
+		t.Fatalf("expected matched replace to carry its pinned version, got %q", targets[0].Version)
+	}
+
+	for _, target := range targets {
+		if err := f.DropReplace(target.Path, target.Version); err != nil {
+			t.Fatalf("DropReplace: %v", err)
+		}
+	}
+
+	out, err := formatModFile(f)
+	if err != nil {
+		t.Fatalf("formatModFile: %v", err)
+	}
+	if strings.Contains(string(out), "replace") {
+		t.Fatalf("expected the version-pinned replace to be dropped, got:\n%s", out)
+	}
+}
+
+func TestSortedKeys(t *testing.T) {
+	m := map[string]string{"c": "3", "a": "1", "b": "2"}
+	got := sortedKeys(m)
+	want := []string{"a", "b", "c"}
+	if len(got) != len(want) {
+		t.Fatalf("sortedKeys(%v) = %v, want %v", m, got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("sortedKeys(%v) = %v, want %v", m, got, want)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import "testing"
+
+func TestFillJSONReplacementsSingle(t *testing.T) {
+	result := jsonResult{Matches: []string{"example.com/dep"}}
+	fillJSONReplacements(&result, map[string]string{"example.com/dep": "../dep"}, true)
+
+	if result.Selected != "example.com/dep" {
+		t.Errorf("Selected = %q, want %q", result.Selected, "example.com/dep")
+	}
+	if result.LocalPath != "../dep" {
+		t.Errorf("LocalPath = %q, want %q", result.LocalPath, "../dep")
+	}
+	if !result.Replaced {
+		t.Errorf("Replaced = false, want true")
+	}
+	if result.Replacements != nil {
+		t.Errorf("Replacements = %v, want nil for a single selection", result.Replacements)
+	}
+}
+
+func TestFillJSONReplacementsMultiple(t *testing.T) {
+	resolved := map[string]string{"example.com/a": "../a", "example.com/b": "../b"}
+	result := jsonResult{}
+	fillJSONReplacements(&result, resolved, false)
+
+	if result.Replaced {
+		t.Errorf("Replaced = true, want false")
+	}
+	if result.Selected != "" || result.LocalPath != "" {
+		t.Errorf("expected Selected/LocalPath to stay empty for multiple replacements, got %+v", result)
+	}
+	if len(result.Replacements) != len(resolved) {
+		t.Errorf("Replacements = %v, want %v", result.Replacements, resolved)
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// TestLockHelperProcess is not a real test: it's spawned as a subprocess by
+// TestLockFileContention to hold path's lock while the parent tries (and
+// should fail) to acquire it, the same way Go's own os/exec tests use a
+// helper process to exercise behavior that requires two separate processes.
+func TestLockHelperProcess(t *testing.T) {
+	path := os.Getenv("GOREPLACE_LOCK_HELPER_PATH")
+	if path == "" {
+		t.Skip("helper process, not a real test")
+	}
+
+	lock, err := lockFile(path)
+	if err != nil {
+		t.Fatalf("lockFile: %v", err)
+	}
+	defer lock.Unlock()
+
+	os.Stdout.WriteString("locked\n")
+
+	buf := make([]byte, 1)
+	os.Stdin.Read(buf)
+}
+
+func TestLockFileContention(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "go.mod")
+	if err := os.WriteFile(path, []byte("module example.com/app\n\ngo 1.21\n"), 0644); err != nil {
+		t.Fatalf("failed to write go.mod: %v", err)
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestLockHelperProcess$")
+	cmd.Env = append(os.Environ(), "GOREPLACE_LOCK_HELPER_PATH="+path)
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		t.Fatalf("StdinPipe: %v", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatalf("StdoutPipe: %v", err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("failed to start lock helper process: %v", err)
+	}
+	defer func() {
+		stdin.Close()
+		cmd.Wait()
+	}()
+
+	line, err := bufio.NewReader(stdout).ReadString('\n')
+	if err != nil || line != "locked\n" {
+		t.Fatalf("lock helper process didn't report holding the lock (got %q, err %v)", line, err)
+	}
+
+	if _, err := lockFile(path); err == nil {
+		t.Fatal("expected lockFile to fail while another process holds the lock")
+	}
+}
@@ -0,0 +1,41 @@
+//go:build !windows
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// fileLock is an advisory lock on a file, held for the duration of a
+// read-modify-write window so a concurrent goreplace (or `go build`
+// rewriting go.mod) can't race us.
+type fileLock struct {
+	f *os.File
+}
+
+// lockFile takes an exclusive, non-blocking fcntl lock on path. Contention
+// is reported as an error rather than blocking, so goreplace never
+// silently races a concurrent writer.
+func lockFile(path string) (*fileLock, error) {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s for locking: %w", path, err)
+	}
+
+	lock := unix.Flock_t{Type: unix.F_WRLCK, Whence: 0, Start: 0, Len: 0}
+	if err := unix.FcntlFlock(f.Fd(), unix.F_SETLK, &lock); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s is locked by another process: %w", path, err)
+	}
+
+	return &fileLock{f: f}, nil
+}
+
+func (l *fileLock) Unlock() error {
+	defer l.f.Close()
+	unlock := unix.Flock_t{Type: unix.F_UNLCK, Whence: 0, Start: 0, Len: 0}
+	return unix.FcntlFlock(l.f.Fd(), unix.F_SETLK, &unlock)
+}
@@ -0,0 +1,42 @@
+//go:build windows
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// fileLock is an advisory lock on a file, held for the duration of a
+// read-modify-write window so a concurrent goreplace (or `go build`
+// rewriting go.mod) can't race us.
+type fileLock struct {
+	f *os.File
+}
+
+// lockFile takes an exclusive, non-blocking LockFileEx lock on path.
+// Contention is reported as an error rather than blocking, so goreplace
+// never silently races a concurrent writer.
+func lockFile(path string) (*fileLock, error) {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s for locking: %w", path, err)
+	}
+
+	overlapped := new(windows.Overlapped)
+	flags := uint32(windows.LOCKFILE_EXCLUSIVE_LOCK | windows.LOCKFILE_FAIL_IMMEDIATELY)
+	if err := windows.LockFileEx(windows.Handle(f.Fd()), flags, 0, 1, 0, overlapped); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s is locked by another process: %w", path, err)
+	}
+
+	return &fileLock{f: f}, nil
+}
+
+func (l *fileLock) Unlock() error {
+	defer l.f.Close()
+	overlapped := new(windows.Overlapped)
+	return windows.UnlockFileEx(windows.Handle(l.f.Fd()), 0, 1, 0, overlapped)
+}
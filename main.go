@@ -2,17 +2,25 @@ package main
 
 import (
 	"bufio"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
 )
 
-// Colors for terminal output
-const (
+// Colors for terminal output. These are variables rather than constants so
+// disableColors (invoked when stdout isn't a TTY or NO_COLOR is set) can
+// blank them out at startup.
+var (
 	ColorRed    = "\033[31m"
 	ColorGreen  = "\033[32m"
 	ColorYellow = "\033[33m"
@@ -27,6 +35,16 @@ var (
 	showHelp     = flag.Bool("h", false, "Show help")
 	showHelpLong = flag.Bool("help", false, "Show help")
 	showVersion  = flag.Bool("version", false, "Show version")
+	workspace    = flag.Bool("workspace", false, "Search every module listed in go.work instead of ./go.mod")
+	listModules  = flag.Bool("list", false, "List the modules used by go.work and exit")
+	workReplace  = flag.Bool("work-replace", false, "Add the replace directive to go.work itself instead of the owning module's go.mod")
+	yesFlag      = flag.Bool("yes", false, "Skip confirmation prompts")
+	allFlag      = flag.Bool("all", false, "Apply the replace to every matching dependency instead of prompting to disambiguate")
+	dryRun       = flag.Bool("dry-run", false, "Print the change without touching go.mod")
+	jsonOutput   = flag.Bool("json", false, "Emit machine-readable JSON instead of text")
+	dropTarget   = flag.String("drop", "", "Remove the replace directive matching `partial` instead of adding one")
+	undo         = flag.Bool("undo", false, "Alias for -drop, using the positional argument as the partial name")
+	restore      = flag.Bool("restore", false, "Restore go.mod (or go.work, with -workspace) from its .bak backup")
 	maxInputLen  = 256 // Maximum allowed input length
 )
 
@@ -35,6 +53,21 @@ type Dependency struct {
 	Version string
 }
 
+// workspaceModule is one go.work "use" entry: its directory, module path,
+// and parsed go.mod.
+type workspaceModule struct {
+	Dir        string
+	ModulePath string
+	File       *modfile.File
+}
+
+// workspaceMatch pairs a matched dependency with the workspace module it
+// was found in, so the replace can be written to the right go.mod.
+type workspaceMatch struct {
+	Dependency
+	Module workspaceModule
+}
+
 func init() {
 	flag.Usage = func() {
 		fmt.Printf("%sUsage: goreplace <partial-package-name>%s\n", ColorBlue, ColorReset)
@@ -42,6 +75,16 @@ func init() {
 		fmt.Printf("\n%sOptions:%s\n", ColorYellow, ColorReset)
 		fmt.Println("  -h, --help      Show this help message")
 		fmt.Println("  -version        Show version information")
+		fmt.Println("  -workspace      Search every module listed in go.work")
+		fmt.Println("  -list           List the modules used by go.work and exit")
+		fmt.Println("  -work-replace   Add the replace directive to go.work itself")
+		fmt.Println("  -yes            Skip confirmation prompts")
+		fmt.Println("  -all            Apply the replace to every match instead of disambiguating")
+		fmt.Println("  -dry-run        Print the change without touching go.mod")
+		fmt.Println("  -json           Emit machine-readable JSON instead of text")
+		fmt.Println("  -drop partial   Remove a matching replace directive instead of adding one")
+		fmt.Println("  -undo           Alias for -drop using the positional argument")
+		fmt.Println("  -restore        Restore go.mod (or go.work) from its .bak backup")
 		fmt.Printf("\n%sExample:%s\n", ColorYellow, ColorReset)
 		fmt.Println("  goreplace proto")
 	}
@@ -50,6 +93,10 @@ func init() {
 func main() {
 	flag.Parse()
 
+	if disableColors() {
+		ColorRed, ColorGreen, ColorYellow, ColorBlue, ColorReset = "", "", "", "", ""
+	}
+
 	if *showHelp || *showHelpLong {
 		flag.Usage()
 		return
@@ -60,56 +107,223 @@ func main() {
 		return
 	}
 
+	if *restore {
+		target := "go.mod"
+		if *workspace {
+			workPath, err := findGoWork()
+			if err != nil {
+				printError(err.Error())
+				os.Exit(1)
+			}
+			target = workPath
+		}
+
+		if err := restoreBackup(target); err != nil {
+			printError(err.Error())
+			os.Exit(1)
+		}
+
+		printSuccess(fmt.Sprintf("Restored %s from %s", target, backupPathFor(target)))
+		return
+	}
+
+	if *listModules {
+		workPath, err := findGoWork()
+		if err != nil {
+			printError(err.Error())
+			os.Exit(1)
+		}
+
+		work, err := parseGoWork(workPath)
+		if err != nil {
+			printError(err.Error())
+			os.Exit(1)
+		}
+
+		modules, err := loadWorkspaceModules(work, workPath)
+		if err != nil {
+			printError(err.Error())
+			os.Exit(1)
+		}
+
+		printWorkspaceModules(modules)
+		return
+	}
+
 	args := flag.Args()
-	if len(args) < 1 {
-		printError("missing required argument <partial-package-name>")
-		flag.Usage()
-		os.Exit(1)
+	dropMode := *dropTarget != "" || *undo
+
+	partialName := *dropTarget
+	if partialName == "" {
+		if len(args) < 1 {
+			printError("missing required argument <partial-package-name>")
+			flag.Usage()
+			os.Exit(1)
+		}
+		partialName = args[0]
 	}
 
-	partialName := args[0]
 	if len(partialName) > maxInputLen {
 		printError(fmt.Sprintf("input too long (max %d characters)", maxInputLen))
 		os.Exit(1)
 	}
 
-	modContent, err := os.ReadFile("go.mod")
+	if dropMode {
+		runDrop(partialName)
+		return
+	}
+
+	if *workspace {
+		runWorkspace(partialName)
+		return
+	}
+
+	runAdd(partialName)
+}
+
+// disableColors reports whether ANSI colors should be suppressed: when
+// NO_COLOR is set, or stdout isn't a terminal (piped into a script, CI,
+// editor integration, etc).
+func disableColors() bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return true
+	}
+	return !isTerminal(os.Stdout)
+}
+
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return fi.Mode()&os.ModeCharDevice != 0
+}
+
+// suppressPrompts reports whether interactive prompts (disambiguation,
+// confirmation) should be skipped in favor of a best-effort automatic
+// choice, because the caller asked for scriptable behavior.
+func suppressPrompts() bool {
+	return *yesFlag || *jsonOutput || *dryRun
+}
+
+// runAdd is the default entry point: find every dependency matching
+// partialName in go.mod, resolve a local path for each one selected, and
+// add (or preview/emit) the corresponding replace directives.
+func runAdd(partialName string) {
+	lock, err := lockFile("go.mod")
+	if err != nil {
+		printError(err.Error())
+		os.Exit(1)
+	}
+	defer lock.Unlock()
+
+	modFile, err := parseGoMod("go.mod")
 	if err != nil {
 		printError(fmt.Sprintf("error reading go.mod: %v", err))
 		os.Exit(1)
 	}
 
-	dependencies, replaces := parseGoMod(string(modContent))
+	dependencies, replaces := dependenciesFromModFile(modFile)
 	matched := filterDependencies(dependencies, replaces, partialName)
 
 	if len(matched) == 0 {
-		fmt.Println("No matches found.")
+		if *jsonOutput {
+			printJSON(jsonResult{Matches: []string{}})
+		} else {
+			fmt.Println("No matches found.")
+		}
 		return
 	}
 
-	selected, err := selectDependency(matched)
-	if err != nil {
-		printError(err.Error())
-		os.Exit(1)
+	var selections []Dependency
+	if *allFlag {
+		selections = matched
+	} else {
+		selected, err := selectDependency(matched)
+		if err != nil {
+			printError(err.Error())
+			os.Exit(1)
+		}
+		selections = []Dependency{selected}
+	}
+
+	resolved := make(map[string]string)
+	for _, dep := range selections {
+		if !confirmSelection(dep.Path) {
+			continue
+		}
+
+		localPath, err := findLocalPath(dep)
+		if err != nil {
+			printError(err.Error())
+			os.Exit(1)
+		}
+		resolved[dep.Path] = localPath
 	}
 
-	if !confirmSelection(selected) {
-		fmt.Println("Operation canceled.")
+	result := jsonResult{Matches: dependencyPaths(matched)}
+
+	if len(resolved) == 0 {
+		if *jsonOutput {
+			printJSON(result)
+		} else {
+			fmt.Println("Operation canceled.")
+		}
 		return
 	}
 
-	localPath, err := findLocalPath(selected)
+	paths := sortedKeys(resolved)
+
+	for _, path := range paths {
+		if err := applyReplace(modFile, path, resolved[path]); err != nil {
+			printError(err.Error())
+			os.Exit(1)
+		}
+	}
+
+	out, err := formatModFile(modFile)
 	if err != nil {
 		printError(err.Error())
 		os.Exit(1)
 	}
 
-	if err := replaceInGoMod(selected, localPath); err != nil {
+	fillJSONReplacements(&result, resolved, !*dryRun)
+
+	if *dryRun {
+		if !*jsonOutput {
+			for _, path := range paths {
+				fmt.Printf("+replace %s => %s\n", path, resolved[path])
+			}
+		} else {
+			printJSON(result)
+		}
+		return
+	}
+
+	if err := writeLocked("go.mod", out); err != nil {
 		printError(fmt.Sprintf("failed to update go.mod: %v", err))
 		os.Exit(1)
 	}
 
-	printSuccess(fmt.Sprintf("Added replace: %s => %s", selected, localPath))
+	if *jsonOutput {
+		printJSON(result)
+		return
+	}
+
+	for _, path := range paths {
+		printSuccess(fmt.Sprintf("Added replace: %s => %s", path, resolved[path]))
+	}
+}
+
+// sortedKeys returns m's keys in ascending order, so map-driven output
+// (dry-run previews, applied replaces) is deterministic.
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
 }
 
 func printError(msg string) {
@@ -135,22 +349,279 @@ func confirmAlternativePath() bool {
 	return strings.TrimSpace(strings.ToLower(input)) != "n"
 }
 
-func findLocalPath(modulePath string) (string, error) {
-	originalPath := filepath.Join(os.Getenv("GOPATH"), "src", modulePath)
-	if _, err := os.Stat(originalPath); err == nil {
-		return originalPath, nil
+// resolvedCandidate is one directory findLocalPath considered for a
+// module, along with how it was found and a score used to rank guesses
+// when more than one directory looks plausible.
+type resolvedCandidate struct {
+	Path   string
+	Source string
+	Score  int
+}
+
+// findLocalPath looks for a local working copy of dep across every
+// resolver goreplace knows about ($GOPATH/src, GOMODCACHE, GOREPLACE_PATH,
+// config.toml, and `go list -m` plus a matching git remote), then returns
+// the best match, asking the user to disambiguate if several are found.
+func findLocalPath(dep Dependency) (string, error) {
+	candidates := findLocalPathCandidates(dep)
+	if len(candidates) == 0 {
+		return "", fmt.Errorf("local copy not found for %s", dep.Path)
 	}
 
-	basePath := removeVersionFromPath(modulePath)
-	if basePath != modulePath {
-		versionlessPath := filepath.Join(os.Getenv("GOPATH"), "src", basePath)
-		if _, err := os.Stat(versionlessPath); err == nil {
-			return versionlessPath, nil
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Score > candidates[j].Score
+	})
+
+	if len(candidates) == 1 {
+		return candidates[0].Path, nil
+	}
+
+	return selectCandidate(dep.Path, candidates)
+}
+
+func findLocalPathCandidates(dep Dependency) []resolvedCandidate {
+	var candidates []resolvedCandidate
+	seen := make(map[string]bool)
+
+	add := func(path, source string, score int) {
+		if path == "" || seen[path] {
+			return
+		}
+		if _, err := os.Stat(path); err != nil {
+			return
+		}
+		seen[path] = true
+		candidates = append(candidates, resolvedCandidate{Path: path, Source: source, Score: score})
+	}
+
+	basePath := removeVersionFromPath(dep.Path)
+
+	add(filepath.Join(os.Getenv("GOPATH"), "src", dep.Path), "GOPATH/src", 40)
+	if basePath != dep.Path {
+		add(filepath.Join(os.Getenv("GOPATH"), "src", basePath), "GOPATH/src (versionless)", 35)
+	}
+
+	if cache := gomodCacheDir(); cache != "" && dep.Version != "" {
+		if escaped, err := module.EscapePath(dep.Path); err == nil {
+			add(filepath.Join(cache, escaped+"@"+dep.Version), "GOMODCACHE", 30)
+		}
+	}
+
+	for _, root := range searchRoots() {
+		add(filepath.Join(root, dep.Path), "GOREPLACE_PATH", 60)
+		if basePath != dep.Path {
+			add(filepath.Join(root, basePath), "GOREPLACE_PATH (versionless)", 55)
 		}
+		add(filepath.Join(root, filepath.Base(dep.Path)), "GOREPLACE_PATH (basename)", 50)
 	}
 
-	return "", fmt.Errorf("local copy not found: tried %s and %s", originalPath,
-		filepath.Join(os.Getenv("GOPATH"), "src", basePath))
+	if dir, ok := configuredPath(dep.Path); ok {
+		add(dir, "config.toml", 70)
+	}
+
+	if mod, err := goListModule(dep.Path); err == nil {
+		add(mod.Dir, "go list -m Dir", 45)
+		if mod.Origin != nil && mod.Origin.URL != "" {
+			for _, root := range searchRoots() {
+				for _, dir := range gitWorkingCopiesMatchingRemote(root, mod.Origin.URL) {
+					add(dir, "git remote match", 65)
+				}
+			}
+		}
+	}
+
+	return candidates
+}
+
+// selectCandidate prompts the user to pick among several plausible local
+// directories for modulePath, the same way selectDependency disambiguates
+// multiple matching dependencies.
+func selectCandidate(modulePath string, candidates []resolvedCandidate) (string, error) {
+	if suppressPrompts() {
+		return candidates[0].Path, nil
+	}
+
+	fmt.Printf("\n%sMultiple local candidates found for %s:%s\n", ColorYellow, modulePath, ColorReset)
+	for i, c := range candidates {
+		fmt.Printf("%s%d) %s%s %s(%s)%s\n", ColorBlue, i+1, c.Path, ColorReset, ColorYellow, c.Source, ColorReset)
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+	fmt.Printf("%sEnter the number of the desired path:%s ", ColorYellow, ColorReset)
+	input, err := reader.ReadString('\n')
+	if err != nil {
+		return "", fmt.Errorf("failed to read input")
+	}
+
+	input = strings.TrimSpace(input)
+	if len(input) > maxInputLen {
+		return "", fmt.Errorf("input too long")
+	}
+
+	idx, err := strconv.Atoi(input)
+	if err != nil || idx < 1 || idx > len(candidates) {
+		return "", fmt.Errorf("invalid selection")
+	}
+
+	return candidates[idx-1].Path, nil
+}
+
+// gomodCacheDir reports the module download cache directory, honoring an
+// explicit $GOMODCACHE, then falling back to `go env GOMODCACHE` and
+// finally $GOPATH/pkg/mod.
+func gomodCacheDir() string {
+	if v := os.Getenv("GOMODCACHE"); v != "" {
+		return v
+	}
+
+	if out, err := exec.Command("go", "env", "GOMODCACHE").Output(); err == nil {
+		if dir := strings.TrimSpace(string(out)); dir != "" {
+			return dir
+		}
+	}
+
+	if gopath := os.Getenv("GOPATH"); gopath != "" {
+		return filepath.Join(gopath, "pkg", "mod")
+	}
+
+	return ""
+}
+
+// searchRoots returns the colon-separated directories configured in
+// $GOREPLACE_PATH, in order.
+func searchRoots() []string {
+	v := os.Getenv("GOREPLACE_PATH")
+	if v == "" {
+		return nil
+	}
+	return filepath.SplitList(v)
+}
+
+// config mirrors ~/.config/goreplace/config.toml: a single [paths] table
+// mapping a module path prefix to a local directory.
+type config struct {
+	Paths map[string]string
+}
+
+func loadConfig() (*config, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return &config{}, nil
+	}
+
+	path := filepath.Join(home, ".config", "goreplace", "config.toml")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &config{}, nil
+		}
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	return parseConfigTOML(data), nil
+}
+
+// parseConfigTOML understands just enough TOML for config.toml's single
+// [paths] table of quoted `key = "value"` pairs.
+func parseConfigTOML(data []byte) *config {
+	cfg := &config{Paths: make(map[string]string)}
+	inPaths := false
+
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			inPaths = line == "[paths]"
+			continue
+		}
+		if !inPaths {
+			continue
+		}
+
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		key := strings.Trim(strings.TrimSpace(parts[0]), `"`)
+		value := strings.Trim(strings.TrimSpace(parts[1]), `"`)
+		if key != "" && value != "" {
+			cfg.Paths[key] = value
+		}
+	}
+
+	return cfg
+}
+
+// configuredPath returns the longest [paths] prefix in config.toml that
+// matches modulePath.
+func configuredPath(modulePath string) (string, bool) {
+	cfg, err := loadConfig()
+	if err != nil || cfg == nil {
+		return "", false
+	}
+
+	var bestPrefix, bestDir string
+	for prefix, dir := range cfg.Paths {
+		if modulePath != prefix && !strings.HasPrefix(modulePath, prefix+"/") {
+			continue
+		}
+		if len(prefix) > len(bestPrefix) {
+			bestPrefix, bestDir = prefix, dir
+		}
+	}
+
+	return bestDir, bestPrefix != ""
+}
+
+// goModuleInfo is the subset of `go list -m -json` output goreplace needs.
+type goModuleInfo struct {
+	Path   string `json:"Path"`
+	Dir    string `json:"Dir"`
+	Origin *struct {
+		URL string `json:"URL"`
+	} `json:"Origin"`
+}
+
+func goListModule(modulePath string) (*goModuleInfo, error) {
+	out, err := exec.Command("go", "list", "-m", "-json", modulePath).Output()
+	if err != nil {
+		return nil, fmt.Errorf("go list -m -json %s: %w", modulePath, err)
+	}
+
+	var mod goModuleInfo
+	if err := json.Unmarshal(out, &mod); err != nil {
+		return nil, fmt.Errorf("failed to parse go list output: %w", err)
+	}
+
+	return &mod, nil
+}
+
+// gitWorkingCopiesMatchingRemote lists immediate subdirectories of root
+// that are git working copies whose "origin" remote matches remoteURL.
+func gitWorkingCopiesMatchingRemote(root, remoteURL string) []string {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil
+	}
+
+	var dirs []string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		dir := filepath.Join(root, entry.Name())
+		out, err := exec.Command("git", "-C", dir, "remote", "get-url", "origin").Output()
+		if err != nil {
+			continue
+		}
+		if strings.TrimSpace(string(out)) == remoteURL {
+			dirs = append(dirs, dir)
+		}
+	}
+
+	return dirs
 }
 
 func removeVersionFromPath(path string) string {
@@ -158,137 +629,736 @@ func removeVersionFromPath(path string) string {
 	return re.ReplaceAllString(path, "")
 }
 
-func parseGoMod(content string) ([]Dependency, map[string]bool) {
+// parseGoMod reads and parses the go.mod file at path into its AST, so
+// callers can inspect require/replace/exclude/retract directives (including
+// block form) without re-deriving them from raw text.
+func parseGoMod(path string) (*modfile.File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	f, err := modfile.Parse(path, data, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	return f, nil
+}
+
+// dependenciesFromModFile walks the parsed go.mod and reports direct
+// (non-indirect) requirements alongside the set of modules that already
+// have a replace directive.
+func dependenciesFromModFile(f *modfile.File) ([]Dependency, map[string]bool) {
 	var dependencies []Dependency
 	replaces := make(map[string]bool)
 
-	lines := strings.Split(content, "\n")
-	inRequire := false
+	for _, req := range f.Require {
+		if req.Indirect {
+			continue
+		}
+		dependencies = append(dependencies, Dependency{
+			Path:    req.Mod.Path,
+			Version: req.Mod.Version,
+		})
+	}
 
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line == "" {
+	for _, rep := range f.Replace {
+		replaces[rep.Old.Path] = true
+	}
+
+	return dependencies, replaces
+}
+
+// findGoWork walks upward from the current directory looking for a
+// go.work file, the same way cmd/go resolves workspace mode.
+func findGoWork() (string, error) {
+	dir, err := os.Getwd()
+	if err != nil {
+		return "", fmt.Errorf("failed to get working directory: %w", err)
+	}
+
+	for {
+		candidate := filepath.Join(dir, "go.work")
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate, nil
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", fmt.Errorf("no go.work file found (searched upward from %s)", dir)
+		}
+		dir = parent
+	}
+}
+
+// parseGoWork reads and parses the go.work file at path into its AST.
+func parseGoWork(path string) (*modfile.WorkFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	w, err := modfile.ParseWork(path, data, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	return w, nil
+}
+
+// loadWorkspaceModules resolves every "use" directive in work to its
+// go.mod, parsed relative to the directory holding the go.work file.
+func loadWorkspaceModules(work *modfile.WorkFile, workPath string) ([]workspaceModule, error) {
+	workDir := filepath.Dir(workPath)
+
+	var modules []workspaceModule
+	for _, use := range work.Use {
+		dir := use.Path
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(workDir, dir)
+		}
+
+		modPath := filepath.Join(dir, "go.mod")
+		f, err := parseGoMod(modPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load module used by go.work in %s: %w", dir, err)
+		}
+
+		modules = append(modules, workspaceModule{
+			Dir:        dir,
+			ModulePath: f.Module.Mod.Path,
+			File:       f,
+		})
+	}
+
+	return modules, nil
+}
+
+func printWorkspaceModules(modules []workspaceModule) {
+	for _, m := range modules {
+		fmt.Printf("%s%s%s -> %s\n", ColorBlue, m.ModulePath, ColorReset, m.Dir)
+	}
+}
+
+// runWorkspace is the -workspace entry point: it searches every module
+// used by go.work for partialName and writes the replace into whichever
+// module's go.mod the user selects (or into go.work itself if -work-replace
+// is set).
+func runWorkspace(partialName string) {
+	workPath, err := findGoWork()
+	if err != nil {
+		printError(err.Error())
+		os.Exit(1)
+	}
+
+	lock, err := lockFile(workPath)
+	if err != nil {
+		printError(err.Error())
+		os.Exit(1)
+	}
+	defer lock.Unlock()
+
+	work, err := parseGoWork(workPath)
+	if err != nil {
+		printError(err.Error())
+		os.Exit(1)
+	}
+
+	modules, err := loadWorkspaceModules(work, workPath)
+	if err != nil {
+		printError(err.Error())
+		os.Exit(1)
+	}
+
+	var matched []workspaceMatch
+	for _, m := range modules {
+		deps, replaces := dependenciesFromModFile(m.File)
+		for _, dep := range filterDependencies(deps, replaces, partialName) {
+			matched = append(matched, workspaceMatch{Dependency: dep, Module: m})
+		}
+	}
+
+	if len(matched) == 0 {
+		fmt.Println("No matches found.")
+		return
+	}
+
+	var selections []workspaceMatch
+	if *allFlag {
+		selections = matched
+	} else {
+		selected, err := selectWorkspaceMatch(matched)
+		if err != nil {
+			printError(err.Error())
+			os.Exit(1)
+		}
+		selections = []workspaceMatch{selected}
+	}
+
+	for _, selected := range selections {
+		if !confirmSelection(selected.Path) {
 			continue
 		}
 
-		switch {
-		case strings.HasPrefix(line, "require ("):
-			inRequire = true
-		case strings.HasPrefix(line, "require ") && !inRequire:
-			line = strings.TrimPrefix(line, "require ")
-			if dep := parseRequireLine(line); dep != nil {
-				dependencies = append(dependencies, *dep)
-			}
-		case inRequire && line == ")":
-			inRequire = false
-		case inRequire:
-			if dep := parseRequireLine(line); dep != nil {
-				dependencies = append(dependencies, *dep)
+		localPath, err := findLocalPath(selected.Dependency)
+		if err != nil {
+			printError(err.Error())
+			os.Exit(1)
+		}
+
+		if *dryRun {
+			fmt.Printf("+replace %s => %s\n", selected.Path, localPath)
+			continue
+		}
+
+		if *workReplace {
+			if err := AddWorkReplace(work, selected.Path, localPath); err != nil {
+				printError(err.Error())
+				os.Exit(1)
 			}
-		case strings.HasPrefix(line, "replace "):
-			if path := extractReplacePath(line); path != "" {
-				replaces[path] = true
+			if err := writeGoWork(work, workPath); err != nil {
+				printError(fmt.Sprintf("failed to update go.work: %v", err))
+				os.Exit(1)
 			}
+			printSuccess(fmt.Sprintf("Added replace in go.work: %s => %s", selected.Path, localPath))
+			continue
 		}
-	}
 
-	return dependencies, replaces
+		modPath := filepath.Join(selected.Module.Dir, "go.mod")
+		if err := replaceInGoMod(selected.Module.File, modPath, selected.Path, localPath); err != nil {
+			printError(fmt.Sprintf("failed to update %s: %v", modPath, err))
+			os.Exit(1)
+		}
+
+		printSuccess(fmt.Sprintf("Added replace in %s: %s => %s", selected.Module.ModulePath, selected.Path, localPath))
+	}
 }
 
-func parseRequireLine(line string) *Dependency {
-	if strings.Contains(line, "indirect") {
-		return nil
+// selectWorkspaceMatch is selectDependency's workspace-aware counterpart:
+// it additionally shows which module each match came from.
+func selectWorkspaceMatch(matched []workspaceMatch) (workspaceMatch, error) {
+	if len(matched) == 1 {
+		return matched[0], nil
 	}
 
-	if idx := strings.Index(line, "//"); idx != -1 {
-		line = strings.TrimSpace(line[:idx])
+	if suppressPrompts() {
+		return workspaceMatch{}, fmt.Errorf("multiple matches found; re-run with -all or a more specific name")
 	}
 
-	parts := strings.Fields(line)
-	if len(parts) < 2 {
-		return nil
+	fmt.Printf("\n%sMultiple matches found:%s\n", ColorYellow, ColorReset)
+	for i, m := range matched {
+		fmt.Printf("%s%d) %s%s %s(%s)%s\n", ColorBlue, i+1, m.Path, ColorReset, ColorYellow, m.Module.ModulePath, ColorReset)
 	}
 
-	return &Dependency{
-		Path:    parts[0],
-		Version: parts[1],
+	reader := bufio.NewReader(os.Stdin)
+	fmt.Printf("%sEnter the number of the desired package:%s ", ColorYellow, ColorReset)
+	input, err := reader.ReadString('\n')
+	if err != nil {
+		return workspaceMatch{}, fmt.Errorf("failed to read input")
 	}
+
+	input = strings.TrimSpace(input)
+	if len(input) > maxInputLen {
+		return workspaceMatch{}, fmt.Errorf("input too long")
+	}
+
+	idx, err := strconv.Atoi(input)
+	if err != nil || idx < 1 || idx > len(matched) {
+		return workspaceMatch{}, fmt.Errorf("invalid selection")
+	}
+
+	return matched[idx-1], nil
 }
 
-func extractReplacePath(line string) string {
-	parts := strings.Split(line, "=>")
-	if len(parts) < 2 {
-		return ""
+// AddWorkReplace adds (or updates in place) a replace directive in a
+// go.work file, mirroring modfile.File's AddReplace for the workspace case.
+func AddWorkReplace(w *modfile.WorkFile, module, localPath string) error {
+	if err := w.AddReplace(module, "", localPath, ""); err != nil {
+		return fmt.Errorf("failed to add replace directive to go.work: %w", err)
 	}
-	return strings.TrimSpace(strings.TrimPrefix(parts[0], "replace "))
+	return nil
+}
+
+// writeGoWork formats w and writes it back to path atomically. Callers hold
+// path's lock for the whole read-modify-write window, so this writes under
+// that lock rather than taking its own.
+func writeGoWork(w *modfile.WorkFile, path string) error {
+	w.Cleanup()
+
+	return writeLocked(path, modfile.Format(w.Syntax))
 }
 
-func filterDependencies(deps []Dependency, replaces map[string]bool, partialName string) []string {
-	var matched []string
+func filterDependencies(deps []Dependency, replaces map[string]bool, partialName string) []Dependency {
+	var matched []Dependency
 	for _, dep := range deps {
 		if replaces[dep.Path] {
 			continue
 		}
 		if strings.Contains(dep.Path, partialName) {
-			matched = append(matched, dep.Path)
+			matched = append(matched, dep)
 		}
 	}
 	return matched
 }
 
-func selectDependency(matched []string) (string, error) {
+func selectDependency(matched []Dependency) (Dependency, error) {
 	if len(matched) == 1 {
 		return matched[0], nil
 	}
 
+	if suppressPrompts() {
+		return Dependency{}, fmt.Errorf("multiple matches found; re-run with -all or a more specific name")
+	}
+
 	fmt.Printf("\n%sMultiple matches found:%s\n", ColorYellow, ColorReset)
 	for i, m := range matched {
-		fmt.Printf("%s%d) %s%s\n", ColorBlue, i+1, m, ColorReset)
+		fmt.Printf("%s%d) %s%s\n", ColorBlue, i+1, m.Path, ColorReset)
 	}
 
 	reader := bufio.NewReader(os.Stdin)
 	fmt.Printf("%sEnter the number of the desired package:%s ", ColorYellow, ColorReset)
 	input, err := reader.ReadString('\n')
 	if err != nil {
-		return "", fmt.Errorf("failed to read input")
+		return Dependency{}, fmt.Errorf("failed to read input")
 	}
 
 	input = strings.TrimSpace(input)
 	if len(input) > maxInputLen {
-		return "", fmt.Errorf("input too long")
+		return Dependency{}, fmt.Errorf("input too long")
 	}
 
 	idx, err := strconv.Atoi(input)
 	if err != nil || idx < 1 || idx > len(matched) {
-		return "", fmt.Errorf("invalid selection")
+		return Dependency{}, fmt.Errorf("invalid selection")
 	}
 
 	return matched[idx-1], nil
 }
 
 func confirmSelection(selected string) bool {
+	if suppressPrompts() {
+		return true
+	}
+
 	fmt.Printf("\n%sYou selected:%s %s%s%s\n", ColorYellow, ColorReset, ColorGreen, selected, ColorReset)
 	fmt.Printf("%sConfirm selection (press Enter to continue, any other key to cancel):%s ", ColorYellow, ColorReset)
 	confirm, _ := bufio.NewReader(os.Stdin).ReadString('\n')
 	return strings.TrimSpace(confirm) == ""
 }
 
-func replaceInGoMod(module, localPath string) error {
-	content, err := os.ReadFile("go.mod")
+// applyReplace adds (or updates in place) a replace directive pointing
+// module at localPath in f, without writing anything to disk. If module
+// already has a version-pinned replace, that pin is preserved: AddReplace
+// treats an empty old version as "match any version", so passing it
+// unconditionally would silently widen an existing `replace foo v1.2.3 =>
+// ...` to an unpinned `replace foo => ...`.
+func applyReplace(f *modfile.File, module, localPath string) error {
+	oldVers, err := existingReplaceVersion(f.Replace, module)
 	if err != nil {
-		return fmt.Errorf("failed to read go.mod: %w", err)
+		return err
+	}
+
+	if err := f.AddReplace(module, oldVers, localPath, ""); err != nil {
+		return fmt.Errorf("failed to add replace directive: %w", err)
 	}
+	return nil
+}
 
-	newReplace := fmt.Sprintf("\nreplace %s => %s\n", module, localPath)
+// existingReplaceVersion reports the version module's existing replace
+// directive (if any) is pinned to, so applyReplace can preserve it. If
+// module has more than one replace directive pinned to different versions,
+// it's ambiguous which one goreplace should update in place, so this
+// returns an error rather than guessing.
+func existingReplaceVersion(replaces []*modfile.Replace, module string) (string, error) {
+	var (
+		found bool
+		vers  string
+	)
+	for _, r := range replaces {
+		if r.Old.Path != module {
+			continue
+		}
+		if found && r.Old.Version != vers {
+			return "", fmt.Errorf("%s has multiple version-pinned replace directives; drop them with -drop before re-adding", module)
+		}
+		found, vers = true, r.Old.Version
+	}
+	return vers, nil
+}
+
+// formatModFile cleans up and formats f, returning the bytes that would
+// be written to go.mod.
+func formatModFile(f *modfile.File) ([]byte, error) {
+	f.Cleanup()
+	out, err := f.Format()
+	if err != nil {
+		return nil, fmt.Errorf("failed to format go.mod: %w", err)
+	}
+	return out, nil
+}
+
+// writeFileAtomic locks path for the write window, backs it up to
+// path+".bak", then writes data via a temp file in the same directory
+// followed by an fsync'd rename, so readers never see a half-written file
+// and a failed write leaves a way back via -restore.
+func writeFileAtomic(path string, data []byte) error {
+	lock, err := lockFile(path)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	return writeLocked(path, data)
+}
+
+// writeLocked backs path up and writes data atomically, assuming the caller
+// already holds path's lock (typically from well before the read that
+// produced data, so the whole read-modify-write is covered).
+func writeLocked(path string, data []byte) error {
+	if err := backupFile(path, backupPathFor(path)); err != nil {
+		return err
+	}
 
-	tmpFile := "go.mod.tmp"
-	if err := os.WriteFile(tmpFile, append(content, newReplace...), 0644); err != nil {
+	return atomicWriteNoLock(path, data)
+}
+
+// atomicWriteNoLock performs the temp-file-then-rename write itself,
+// without acquiring a lock; callers that already hold one (writeFileAtomic,
+// restoreBackup) call this directly.
+func atomicWriteNoLock(path string, data []byte) error {
+	dir := filepath.Dir(path)
+
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	tmpPath := tmp.Name()
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
 		return fmt.Errorf("failed to write temp file: %w", err)
 	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to fsync temp file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to close temp file: %w", err)
+	}
 
-	if err := os.Rename(tmpFile, "go.mod"); err != nil {
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
 		return fmt.Errorf("failed to rename temp file: %w", err)
 	}
 
+	return fsyncDir(dir)
+}
+
+func fsyncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", dir, err)
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+func backupPathFor(path string) string {
+	return path + ".bak"
+}
+
+// backupFile copies path to backupPath, if path exists. It is a no-op for
+// a file that doesn't exist yet (nothing to roll back to).
+func backupFile(path, backupPath string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read %s for backup: %w", path, err)
+	}
+	if err := os.WriteFile(backupPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write backup %s: %w", backupPath, err)
+	}
 	return nil
 }
+
+// restoreBackup swaps path's ".bak" backup back in, locking path for the
+// duration so it can't race a concurrent write.
+func restoreBackup(path string) error {
+	backupPath := backupPathFor(path)
+	data, err := os.ReadFile(backupPath)
+	if err != nil {
+		return fmt.Errorf("failed to read backup %s: %w", backupPath, err)
+	}
+
+	lock, err := lockFile(path)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	return atomicWriteNoLock(path, data)
+}
+
+// jsonResult is the -json output shape: the full set of matches considered,
+// and either a single selected/localPath pair or, under -all, a
+// path-to-localPath map in Replacements.
+type jsonResult struct {
+	Matches      []string          `json:"matches"`
+	Selected     string            `json:"selected,omitempty"`
+	LocalPath    string            `json:"localPath,omitempty"`
+	Replaced     bool              `json:"replaced"`
+	Replacements map[string]string `json:"replacements,omitempty"`
+}
+
+func dependencyPaths(deps []Dependency) []string {
+	paths := make([]string, len(deps))
+	for i, d := range deps {
+		paths[i] = d.Path
+	}
+	return paths
+}
+
+func fillJSONReplacements(result *jsonResult, resolved map[string]string, replaced bool) {
+	result.Replaced = replaced
+	if len(resolved) == 1 {
+		for path, localPath := range resolved {
+			result.Selected = path
+			result.LocalPath = localPath
+		}
+		return
+	}
+	result.Replacements = resolved
+}
+
+func printJSON(v interface{}) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		printError(fmt.Sprintf("failed to encode JSON: %v", err))
+		os.Exit(1)
+	}
+}
+
+// runDrop implements -drop/-undo: remove every replace directive matching
+// partialName instead of adding a new one.
+func runDrop(partialName string) {
+	if *workspace {
+		dropWorkspaceReplace(partialName)
+		return
+	}
+
+	lock, err := lockFile("go.mod")
+	if err != nil {
+		printError(err.Error())
+		os.Exit(1)
+	}
+	defer lock.Unlock()
+
+	modFile, err := parseGoMod("go.mod")
+	if err != nil {
+		printError(fmt.Sprintf("error reading go.mod: %v", err))
+		os.Exit(1)
+	}
+
+	targets := matchingReplaces(modFile, partialName)
+	if len(targets) == 0 {
+		if *jsonOutput {
+			printJSON(jsonResult{Matches: []string{}})
+		} else {
+			fmt.Println("No matching replace directives found.")
+		}
+		return
+	}
+	paths := replaceTargetPaths(targets)
+
+	for _, t := range targets {
+		if err := modFile.DropReplace(t.Path, t.Version); err != nil {
+			printError(fmt.Sprintf("failed to drop replace for %s: %v", t.Path, err))
+			os.Exit(1)
+		}
+	}
+
+	out, err := formatModFile(modFile)
+	if err != nil {
+		printError(err.Error())
+		os.Exit(1)
+	}
+
+	if *dryRun {
+		if !*jsonOutput {
+			for _, path := range paths {
+				fmt.Printf("-replace %s\n", path)
+			}
+		} else {
+			printJSON(jsonResult{Matches: paths, Replaced: false})
+		}
+		return
+	}
+
+	if err := writeLocked("go.mod", out); err != nil {
+		printError(fmt.Sprintf("failed to update go.mod: %v", err))
+		os.Exit(1)
+	}
+
+	if *jsonOutput {
+		printJSON(jsonResult{Matches: paths, Replaced: true})
+		return
+	}
+
+	printSuccess(fmt.Sprintf("Dropped replace: %s", strings.Join(paths, ", ")))
+}
+
+// replaceTarget is a replace directive matched for removal: its old module
+// path and the exact old version it was pinned to (empty for an unversioned
+// replace), since modfile's DropReplace requires an exact version match.
+type replaceTarget struct {
+	Path    string
+	Version string
+}
+
+func replaceTargetPaths(targets []replaceTarget) []string {
+	paths := make([]string, len(targets))
+	for i, t := range targets {
+		paths[i] = t.Path
+	}
+	return paths
+}
+
+func matchingReplaces(f *modfile.File, partialName string) []replaceTarget {
+	var matches []replaceTarget
+	for _, r := range f.Replace {
+		if strings.Contains(r.Old.Path, partialName) {
+			matches = append(matches, replaceTarget{Path: r.Old.Path, Version: r.Old.Version})
+		}
+	}
+	return matches
+}
+
+// dropWorkspaceReplace is runDrop's -workspace counterpart: it drops
+// matching replaces from go.work itself (with -work-replace) or from
+// every used module's go.mod.
+func dropWorkspaceReplace(partialName string) {
+	workPath, err := findGoWork()
+	if err != nil {
+		printError(err.Error())
+		os.Exit(1)
+	}
+
+	lock, err := lockFile(workPath)
+	if err != nil {
+		printError(err.Error())
+		os.Exit(1)
+	}
+	defer lock.Unlock()
+
+	work, err := parseGoWork(workPath)
+	if err != nil {
+		printError(err.Error())
+		os.Exit(1)
+	}
+
+	if *workReplace {
+		targets := matchingWorkReplaces(work, partialName)
+		if len(targets) == 0 {
+			fmt.Println("No matching replace directives found.")
+			return
+		}
+		paths := replaceTargetPaths(targets)
+
+		for _, t := range targets {
+			if err := work.DropReplace(t.Path, t.Version); err != nil {
+				printError(fmt.Sprintf("failed to drop replace for %s: %v", t.Path, err))
+				os.Exit(1)
+			}
+		}
+
+		if *dryRun {
+			for _, path := range paths {
+				fmt.Printf("-replace %s\n", path)
+			}
+			return
+		}
+
+		if err := writeGoWork(work, workPath); err != nil {
+			printError(fmt.Sprintf("failed to update go.work: %v", err))
+			os.Exit(1)
+		}
+
+		printSuccess(fmt.Sprintf("Dropped replace in go.work: %s", strings.Join(paths, ", ")))
+		return
+	}
+
+	modules, err := loadWorkspaceModules(work, workPath)
+	if err != nil {
+		printError(err.Error())
+		os.Exit(1)
+	}
+
+	for _, m := range modules {
+		targets := matchingReplaces(m.File, partialName)
+		if len(targets) == 0 {
+			continue
+		}
+		paths := replaceTargetPaths(targets)
+
+		for _, t := range targets {
+			if err := m.File.DropReplace(t.Path, t.Version); err != nil {
+				printError(fmt.Sprintf("failed to drop replace for %s in %s: %v", t.Path, m.ModulePath, err))
+				os.Exit(1)
+			}
+		}
+
+		out, err := formatModFile(m.File)
+		if err != nil {
+			printError(err.Error())
+			os.Exit(1)
+		}
+
+		modPath := filepath.Join(m.Dir, "go.mod")
+		if *dryRun {
+			for _, path := range paths {
+				fmt.Printf("-replace %s (%s)\n", path, m.ModulePath)
+			}
+			continue
+		}
+
+		if err := writeFileAtomic(modPath, out); err != nil {
+			printError(fmt.Sprintf("failed to update %s: %v", modPath, err))
+			os.Exit(1)
+		}
+
+		printSuccess(fmt.Sprintf("Dropped replace in %s: %s", m.ModulePath, strings.Join(paths, ", ")))
+	}
+}
+
+func matchingWorkReplaces(w *modfile.WorkFile, partialName string) []replaceTarget {
+	var matches []replaceTarget
+	for _, r := range w.Replace {
+		if strings.Contains(r.Old.Path, partialName) {
+			matches = append(matches, replaceTarget{Path: r.Old.Path, Version: r.Old.Version})
+		}
+	}
+	return matches
+}
+
+// replaceInGoMod adds (or updates in place) a replace directive pointing
+// module at localPath, then writes the formatted result back to path
+// atomically via a temp file in the same directory followed by a rename.
+func replaceInGoMod(f *modfile.File, path, module, localPath string) error {
+	if err := applyReplace(f, module, localPath); err != nil {
+		return err
+	}
+
+	out, err := formatModFile(f)
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(path, out)
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestApplyReplacePreservesVersionPin(t *testing.T) {
+	dir := t.TempDir()
+	modPath := filepath.Join(dir, "go.mod")
+	original := "module example.com/app\n\ngo 1.21\n\nrequire example.com/dep v1.0.0\n\nreplace example.com/dep v1.0.0 => ../old\n"
+	if err := os.WriteFile(modPath, []byte(original), 0644); err != nil {
+		t.Fatalf("failed to write go.mod: %v", err)
+	}
+
+	f, err := parseGoMod(modPath)
+	if err != nil {
+		t.Fatalf("parseGoMod: %v", err)
+	}
+
+	if err := applyReplace(f, "example.com/dep", "../new"); err != nil {
+		t.Fatalf("applyReplace: %v", err)
+	}
+
+	out, err := formatModFile(f)
+	if err != nil {
+		t.Fatalf("formatModFile: %v", err)
+	}
+	if !strings.Contains(string(out), "replace example.com/dep v1.0.0 => ../new") {
+		t.Fatalf("expected the version pin to survive the update, got:\n%s", out)
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReplaceInGoMod(t *testing.T) {
+	dir := t.TempDir()
+	modPath := filepath.Join(dir, "go.mod")
+	original := "module example.com/app\n\ngo 1.21\n\nrequire example.com/dep v1.0.0\n"
+	if err := os.WriteFile(modPath, []byte(original), 0644); err != nil {
+		t.Fatalf("failed to write go.mod: %v", err)
+	}
+
+	f, err := parseGoMod(modPath)
+	if err != nil {
+		t.Fatalf("parseGoMod: %v", err)
+	}
+
+	if err := replaceInGoMod(f, modPath, "example.com/dep", "../dep"); err != nil {
+		t.Fatalf("replaceInGoMod: %v", err)
+	}
+
+	out, err := os.ReadFile(modPath)
+	if err != nil {
+		t.Fatalf("failed to read go.mod: %v", err)
+	}
+	if !strings.Contains(string(out), "replace example.com/dep => ../dep") {
+		t.Fatalf("expected replace directive, got:\n%s", out)
+	}
+
+	if _, err := os.Stat(modPath + ".bak"); err != nil {
+		t.Fatalf("expected a .bak backup of the original go.mod: %v", err)
+	}
+}
+
+func TestAddWorkReplace(t *testing.T) {
+	dir := t.TempDir()
+	workPath := filepath.Join(dir, "go.work")
+	original := "go 1.21\n\nuse ./app\n"
+	if err := os.WriteFile(workPath, []byte(original), 0644); err != nil {
+		t.Fatalf("failed to write go.work: %v", err)
+	}
+
+	w, err := parseGoWork(workPath)
+	if err != nil {
+		t.Fatalf("parseGoWork: %v", err)
+	}
+
+	if err := AddWorkReplace(w, "example.com/dep", "../dep"); err != nil {
+		t.Fatalf("AddWorkReplace: %v", err)
+	}
+	if err := writeGoWork(w, workPath); err != nil {
+		t.Fatalf("writeGoWork: %v", err)
+	}
+
+	out, err := os.ReadFile(workPath)
+	if err != nil {
+		t.Fatalf("failed to read go.work: %v", err)
+	}
+	if !strings.Contains(string(out), "replace example.com/dep => ../dep") {
+		t.Fatalf("expected replace directive, got:\n%s", out)
+	}
+}
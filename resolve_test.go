@@ -0,0 +1,75 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/mod/module"
+)
+
+func TestConfiguredPath(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+
+	cfgDir := filepath.Join(home, ".config", "goreplace")
+	if err := os.MkdirAll(cfgDir, 0755); err != nil {
+		t.Fatalf("failed to create config dir: %v", err)
+	}
+
+	toml := "[paths]\n" +
+		"example.com/foo = \"/local/foo\"\n" +
+		"example.com/foo/bar = \"/local/foobar\"\n"
+	if err := os.WriteFile(filepath.Join(cfgDir, "config.toml"), []byte(toml), 0644); err != nil {
+		t.Fatalf("failed to write config.toml: %v", err)
+	}
+
+	tests := []struct {
+		modulePath string
+		wantDir    string
+		wantOK     bool
+	}{
+		{"example.com/foo", "/local/foo", true},
+		{"example.com/foo/bar", "/local/foobar", true},
+		{"example.com/foo/bar/baz", "/local/foobar", true},
+		{"example.com/foobar", "", false},
+		{"example.com/other", "", false},
+	}
+
+	for _, tt := range tests {
+		dir, ok := configuredPath(tt.modulePath)
+		if ok != tt.wantOK || dir != tt.wantDir {
+			t.Errorf("configuredPath(%q) = (%q, %v), want (%q, %v)", tt.modulePath, dir, ok, tt.wantDir, tt.wantOK)
+		}
+	}
+}
+
+func TestFindLocalPathCandidatesGOMODCACHE(t *testing.T) {
+	cache := t.TempDir()
+	t.Setenv("GOMODCACHE", cache)
+
+	dep := Dependency{Path: "example.com/Foo", Version: "v1.0.0"}
+
+	escaped, err := module.EscapePath(dep.Path)
+	if err != nil {
+		t.Fatalf("module.EscapePath: %v", err)
+	}
+
+	modDir := filepath.Join(cache, escaped+"@"+dep.Version)
+	if err := os.MkdirAll(modDir, 0755); err != nil {
+		t.Fatalf("failed to create module cache dir: %v", err)
+	}
+
+	candidates := findLocalPathCandidates(dep)
+
+	var found bool
+	for _, c := range candidates {
+		if c.Path == modDir && c.Source == "GOMODCACHE" {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("expected a GOMODCACHE candidate at %s, got %+v", modDir, candidates)
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRestoreBackup(t *testing.T) {
+	dir := t.TempDir()
+	modPath := filepath.Join(dir, "go.mod")
+	original := []byte("module example.com/app\n\ngo 1.21\n")
+	if err := os.WriteFile(modPath, original, 0644); err != nil {
+		t.Fatalf("failed to write go.mod: %v", err)
+	}
+
+	updated := []byte("module example.com/app\n\ngo 1.21\n\nreplace example.com/dep => ../dep\n")
+	if err := writeFileAtomic(modPath, updated); err != nil {
+		t.Fatalf("writeFileAtomic: %v", err)
+	}
+
+	if err := restoreBackup(modPath); err != nil {
+		t.Fatalf("restoreBackup: %v", err)
+	}
+
+	out, err := os.ReadFile(modPath)
+	if err != nil {
+		t.Fatalf("failed to read go.mod: %v", err)
+	}
+	if string(out) != string(original) {
+		t.Fatalf("restoreBackup did not roll back to the original content, got:\n%s", out)
+	}
+}